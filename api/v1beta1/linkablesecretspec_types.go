@@ -0,0 +1,175 @@
+//
+// Copyright (c) 2021 Red Hat, Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LinkableSecretSpec is the part of the spec of the objects that can have their data linked to
+// other objects in the cluster (ServiceAccounts, pull secrets, etc.) shared by the RemoteSecret
+// and SPIAccessTokenBinding APIs.
+type LinkableSecretSpec struct {
+	// LinkedTo specifies the objects that the secret should be linked to once it is created.
+	// +optional
+	LinkedTo []SecretLink `json:"linkedTo,omitempty"`
+}
+
+// SecretLink describes a single target that the secret produced for the owning object should be
+// linked to.
+type SecretLink struct {
+	// ServiceAccount describes how the secret should be linked to a ServiceAccount.
+	// +optional
+	ServiceAccount ServiceAccountLink `json:"serviceAccount,omitempty"`
+
+	// ProvisionedService, when specified, makes this link resolve the name of the secret to link to
+	// its ServiceAccount by reading the `.status.binding.name` field of the referenced object
+	// instead of requiring the name of the secret to be specified directly. The ServiceAccount
+	// itself still comes from the ServiceAccount field. This follows the "provisioned service"
+	// pattern of the Service Binding spec.
+	// +optional
+	ProvisionedService *ProvisionedServiceReference `json:"provisionedService,omitempty"`
+
+	// Workload, when specified, projects the secret directly into the pod template of the
+	// workloads selected by it, following the workload projection types of the Service Binding
+	// spec (EnvFrom, VolumeMount and the per-key binding directory).
+	// +optional
+	Workload *WorkloadLink `json:"workload,omitempty"`
+}
+
+// WorkloadProjectionType specifies how a secret is projected into the pod template of the
+// workloads selected by a WorkloadLink.
+type WorkloadProjectionType string
+
+const (
+	// WorkloadProjectionTypeEnvFrom projects all the keys of the secret as environment variables
+	// of every container in the pod template, using envFrom.
+	WorkloadProjectionTypeEnvFrom WorkloadProjectionType = "envFrom"
+
+	// WorkloadProjectionTypeVolumeMount mounts the secret as a volume in every container in the
+	// pod template, at the path configured by MountPath. Unlike BindingDirectory, there is no
+	// conventional default path, so MountPath is required for this type.
+	WorkloadProjectionTypeVolumeMount WorkloadProjectionType = "volumeMount"
+
+	// WorkloadProjectionTypeBindingDirectory mounts the secret as a volume, one file per secret
+	// key, at a well-known "binding directory" following the Service Binding spec convention. This
+	// is the default if As is not specified.
+	WorkloadProjectionTypeBindingDirectory WorkloadProjectionType = "bindingDirectory"
+)
+
+// WorkloadLink describes a set of workloads (Deployments, StatefulSets, DaemonSets, Jobs or
+// CronJobs) that the secret should be projected into.
+type WorkloadLink struct {
+	// Kind of the workloads to select. One of Deployment, StatefulSet, DaemonSet, Job, CronJob.
+	Kind string `json:"kind"`
+
+	// Selector selects the workloads of the given Kind, living in the target namespace, that the
+	// secret should be projected into.
+	Selector metav1.LabelSelector `json:"selector"`
+
+	// As specifies how the secret is projected into the selected workloads. Defaults to
+	// WorkloadProjectionTypeBindingDirectory.
+	// +optional
+	As WorkloadProjectionType `json:"as,omitempty"`
+
+	// MountPath is the path at which the secret is mounted for the VolumeMount and
+	// BindingDirectory projection types. Required for VolumeMount; defaults to a path derived from
+	// the secret name for BindingDirectory. Not used for EnvFrom.
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
+}
+
+// ProvisionedServiceReference points to an arbitrary Kubernetes resource that is expected to
+// expose the name of the secret to bind to in its `.status.binding.name` field.
+type ProvisionedServiceReference struct {
+	// Group of the referenced resource.
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Version of the referenced resource.
+	Version string `json:"version"`
+
+	// Kind of the referenced resource.
+	Kind string `json:"kind"`
+
+	// Name of the referenced resource, living in the target namespace of the owning object.
+	Name string `json:"name"`
+}
+
+// ServiceAccountLinkType specifies how the secret should be linked to the ServiceAccount(s)
+// resolved for the link.
+type ServiceAccountLinkType string
+
+const (
+	// ServiceAccountLinkTypeSecret links the secret as a regular mountable secret of the
+	// ServiceAccount. This is the default.
+	ServiceAccountLinkTypeSecret ServiceAccountLinkType = "secret"
+
+	// ServiceAccountLinkTypeImagePullSecret links the secret as an image pull secret of the
+	// ServiceAccount.
+	ServiceAccountLinkTypeImagePullSecret ServiceAccountLinkType = "imagePullSecret"
+)
+
+// ServiceAccountLink specifies how the secret should be linked to a ServiceAccount - either an
+// already existing one (Reference) or one managed by the operator itself (Managed).
+type ServiceAccountLink struct {
+	// As specifies how the secret is linked to the ServiceAccount. The default is to link it as a
+	// regular secret.
+	// +optional
+	As ServiceAccountLinkType `json:"as,omitempty"`
+
+	// Reference is the name of an already existing ServiceAccount living in the target namespace
+	// that the secret should be linked to.
+	// +optional
+	Reference corev1.LocalObjectReference `json:"reference,omitempty"`
+
+	// Managed specifies the parameters of a ServiceAccount that should be created (and later kept
+	// up to date and cleaned up) by the operator.
+	// +optional
+	Managed ManagedServiceAccountSpec `json:"managed,omitempty"`
+}
+
+// ManagedServiceAccountSpec specifies the parameters of a ServiceAccount managed by the operator
+// on behalf of the owning object.
+type ManagedServiceAccountSpec struct {
+	// Name of the managed ServiceAccount. Mutually exclusive with GenerateName.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// GenerateName is used to generate a unique name for the managed ServiceAccount if Name is not
+	// specified.
+	// +optional
+	GenerateName string `json:"generateName,omitempty"`
+
+	// Labels to put on the managed ServiceAccount.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations to put on the managed ServiceAccount.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// TokenAudiences, when non-empty, switches the managed ServiceAccount to using a projected,
+	// bound token obtained through the TokenRequest API (with the given audiences) instead of the
+	// legacy auto-generated `token`/`ca.crt`/`namespace` secret.
+	// +optional
+	TokenAudiences []string `json:"tokenAudiences,omitempty"`
+
+	// TokenExpirationSeconds is the requested lifetime of the projected token. Only used together
+	// with TokenAudiences. Defaults to 1 hour, the same as the TokenRequest API default.
+	// +optional
+	TokenExpirationSeconds *int64 `json:"tokenExpirationSeconds,omitempty"`
+}
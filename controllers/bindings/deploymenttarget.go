@@ -0,0 +1,36 @@
+//
+// Copyright (c) 2021 Red Hat, Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bindings
+
+import (
+	api "github.com/redhat-appstudio/remote-secret/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DeploymentTarget abstracts away the object that owns the secret being linked (e.g. a
+// RemoteSecret or a SPIAccessTokenBinding) so that the handlers in this package can be shared
+// between the two.
+type DeploymentTarget interface {
+	// GetClient returns the client to use when talking to the cluster hosting the target
+	// namespace.
+	GetClient() client.Client
+
+	// GetSpec returns the LinkableSecretSpec configured on the owning object.
+	GetSpec() api.LinkableSecretSpec
+
+	// GetTargetNamespace returns the namespace in which the linked objects (ServiceAccounts,
+	// etc.) live.
+	GetTargetNamespace() string
+}
@@ -0,0 +1,56 @@
+//
+// Copyright (c) 2021 Red Hat, Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bindings
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ObjectMarker is used by the handlers in this package to record, on the linked objects
+// themselves (using labels/annotations), whether they are managed or merely referenced by the
+// currently reconciled owning object. An ObjectMarker implementation is always scoped to a single
+// owning object - the handlers never have to pass the owner's identity around explicitly.
+type ObjectMarker interface {
+	// MarkManaged marks the object as being managed by the current owner. key identifies the
+	// object being marked (not the owner). It returns true if the object was modified.
+	MarkManaged(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error)
+
+	// UnmarkManaged removes the managed marking of the current owner from the object, if present.
+	// It returns true if the object was modified.
+	UnmarkManaged(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error)
+
+	// MarkReferenced marks the object as being referenced (but not managed) by the current owner.
+	// It returns true if the object was modified.
+	MarkReferenced(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error)
+
+	// UnmarkReferenced removes the referenced marking of the current owner from the object, if
+	// present. It returns true if the object was modified.
+	UnmarkReferenced(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error)
+
+	// IsReferencedBy returns true if the object is currently marked as referenced by the current
+	// owner.
+	IsReferencedBy(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error)
+
+	// IsManagedByOther returns true if the object is currently marked as managed by an owner other
+	// than the current one.
+	IsManagedByOther(ctx context.Context, obj client.Object) (bool, error)
+
+	// IsOrphaned returns true if the object is no longer marked as managed or referenced by any
+	// owner, meaning that it is safe to delete (if it is a managed object) or otherwise forget
+	// about it.
+	IsOrphaned(ctx context.Context, obj client.Object) (bool, error)
+}
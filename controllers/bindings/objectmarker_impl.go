@@ -0,0 +1,116 @@
+//
+// Copyright (c) 2021 Red Hat, Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bindings
+
+import (
+	"context"
+
+	"github.com/redhat-appstudio/remote-secret/pkg/commaseparated"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// managedByAnnotation records the UID of the single owner (if any) that is currently managing
+	// (i.e. created and is responsible for the lifecycle of) the marked object.
+	managedByAnnotation = "appstudio.redhat.com/managed-by"
+
+	// linkedByAnnotation records the comma-separated set of UIDs of all the owners (managing or
+	// merely referencing) currently linked to the marked object. An object is safe to clean up
+	// once this set becomes empty.
+	linkedByAnnotation = "appstudio.redhat.com/linked-by"
+)
+
+// annotationObjectMarker is the production ObjectMarker implementation. It is scoped to a single
+// owning object (identified by OwnerUID) and records managed/referenced state directly on the
+// annotations of the marked objects, which makes it trivial to reason about multiple owners
+// sharing (referencing) or fighting over (managing) the same ServiceAccount.
+type annotationObjectMarker struct {
+	// OwnerUID is the UID of the object (e.g. a RemoteSecret) on behalf of which the marking is
+	// performed.
+	OwnerUID types.UID
+}
+
+var _ ObjectMarker = (*annotationObjectMarker)(nil)
+
+func (m *annotationObjectMarker) MarkManaged(_ context.Context, _ client.ObjectKey, obj client.Object) (bool, error) {
+	changed := m.addToLinkedBy(obj)
+
+	anno := obj.GetAnnotations()
+	if anno[managedByAnnotation] != string(m.OwnerUID) {
+		anno[managedByAnnotation] = string(m.OwnerUID)
+		changed = true
+	}
+
+	return changed, nil
+}
+
+func (m *annotationObjectMarker) UnmarkManaged(_ context.Context, _ client.ObjectKey, obj client.Object) (bool, error) {
+	anno := obj.GetAnnotations()
+	if anno[managedByAnnotation] != string(m.OwnerUID) {
+		return false, nil
+	}
+
+	delete(anno, managedByAnnotation)
+	return true, nil
+}
+
+func (m *annotationObjectMarker) MarkReferenced(_ context.Context, _ client.ObjectKey, obj client.Object) (bool, error) {
+	return m.addToLinkedBy(obj), nil
+}
+
+func (m *annotationObjectMarker) UnmarkReferenced(_ context.Context, _ client.ObjectKey, obj client.Object) (bool, error) {
+	anno := obj.GetAnnotations()
+	linked := commaseparated.Value(anno[linkedByAnnotation])
+	if !linked.Contains(string(m.OwnerUID)) {
+		return false, nil
+	}
+
+	anno[linkedByAnnotation] = linked.Remove(string(m.OwnerUID)).String()
+	return true, nil
+}
+
+func (m *annotationObjectMarker) IsReferencedBy(_ context.Context, _ client.ObjectKey, obj client.Object) (bool, error) {
+	linked := commaseparated.Value(obj.GetAnnotations()[linkedByAnnotation])
+	return linked.Contains(string(m.OwnerUID)), nil
+}
+
+func (m *annotationObjectMarker) IsManagedByOther(_ context.Context, obj client.Object) (bool, error) {
+	managedBy := obj.GetAnnotations()[managedByAnnotation]
+	return managedBy != "" && managedBy != string(m.OwnerUID), nil
+}
+
+func (m *annotationObjectMarker) IsOrphaned(_ context.Context, obj client.Object) (bool, error) {
+	linked := commaseparated.Value(obj.GetAnnotations()[linkedByAnnotation])
+	return linked.Len() == 0, nil
+}
+
+// addToLinkedBy adds the owner's UID to the linkedByAnnotation set, initializing the annotations
+// map if necessary. It returns true if the object was modified.
+func (m *annotationObjectMarker) addToLinkedBy(obj client.Object) bool {
+	anno := obj.GetAnnotations()
+	if anno == nil {
+		anno = map[string]string{}
+		obj.SetAnnotations(anno)
+	}
+
+	linked := commaseparated.Value(anno[linkedByAnnotation])
+	if linked.Contains(string(m.OwnerUID)) {
+		return false
+	}
+
+	anno[linkedByAnnotation] = linked.Add(string(m.OwnerUID)).String()
+	return true
+}
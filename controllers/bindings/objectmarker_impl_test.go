@@ -0,0 +1,111 @@
+//
+// Copyright (c) 2021 Red Hat, Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bindings
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestAnnotationObjectMarker(t *testing.T) {
+	t.Run("mark managed also marks referenced", func(t *testing.T) {
+		m := &annotationObjectMarker{OwnerUID: "owner-a"}
+		sa := &corev1.ServiceAccount{}
+
+		changed, err := m.MarkManaged(context.TODO(), client.ObjectKey{}, sa)
+		assert.NoError(t, err)
+		assert.True(t, changed)
+
+		assert.Equal(t, "owner-a", sa.Annotations[managedByAnnotation])
+		assert.Equal(t, "owner-a", sa.Annotations[linkedByAnnotation])
+
+		isOther, err := m.IsManagedByOther(context.TODO(), sa)
+		assert.NoError(t, err)
+		assert.False(t, isOther)
+	})
+
+	t.Run("second manager is rejected", func(t *testing.T) {
+		owner := &annotationObjectMarker{OwnerUID: "owner-a"}
+		other := &annotationObjectMarker{OwnerUID: "owner-b"}
+		sa := &corev1.ServiceAccount{}
+
+		_, err := owner.MarkManaged(context.TODO(), client.ObjectKey{}, sa)
+		assert.NoError(t, err)
+
+		isOther, err := other.IsManagedByOther(context.TODO(), sa)
+		assert.NoError(t, err)
+		assert.True(t, isOther)
+	})
+
+	t.Run("multiple referencers accumulate without duplicates", func(t *testing.T) {
+		a := &annotationObjectMarker{OwnerUID: "owner-a"}
+		b := &annotationObjectMarker{OwnerUID: "owner-b"}
+		sa := &corev1.ServiceAccount{}
+
+		_, err := a.MarkReferenced(context.TODO(), client.ObjectKey{}, sa)
+		assert.NoError(t, err)
+		_, err = b.MarkReferenced(context.TODO(), client.ObjectKey{}, sa)
+		assert.NoError(t, err)
+		changed, err := a.MarkReferenced(context.TODO(), client.ObjectKey{}, sa)
+		assert.NoError(t, err)
+		assert.False(t, changed, "marking an already-referenced owner again should be a no-op")
+
+		refA, err := a.IsReferencedBy(context.TODO(), client.ObjectKey{}, sa)
+		assert.NoError(t, err)
+		assert.True(t, refA)
+
+		refB, err := b.IsReferencedBy(context.TODO(), client.ObjectKey{}, sa)
+		assert.NoError(t, err)
+		assert.True(t, refB)
+
+		orphaned, err := a.IsOrphaned(context.TODO(), sa)
+		assert.NoError(t, err)
+		assert.False(t, orphaned)
+	})
+
+	t.Run("last referencer leaving orphans the object", func(t *testing.T) {
+		a := &annotationObjectMarker{OwnerUID: "owner-a"}
+		b := &annotationObjectMarker{OwnerUID: "owner-b"}
+		sa := &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{},
+			},
+		}
+
+		_, err := a.MarkReferenced(context.TODO(), client.ObjectKey{}, sa)
+		assert.NoError(t, err)
+		_, err = b.MarkReferenced(context.TODO(), client.ObjectKey{}, sa)
+		assert.NoError(t, err)
+
+		_, err = a.UnmarkReferenced(context.TODO(), client.ObjectKey{}, sa)
+		assert.NoError(t, err)
+
+		orphaned, err := a.IsOrphaned(context.TODO(), sa)
+		assert.NoError(t, err)
+		assert.False(t, orphaned, "owner-b is still referencing")
+
+		_, err = b.UnmarkReferenced(context.TODO(), client.ObjectKey{}, sa)
+		assert.NoError(t, err)
+
+		orphaned, err = a.IsOrphaned(context.TODO(), sa)
+		assert.NoError(t, err)
+		assert.True(t, orphaned)
+	})
+}
@@ -0,0 +1,77 @@
+//
+// Copyright (c) 2021 Red Hat, Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bindings
+
+import (
+	"context"
+	"fmt"
+
+	api "github.com/redhat-appstudio/remote-secret/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Projector resolves a set of workloads and projects a secret into them, mirroring the
+// Sync/LinkToSecret/Unlink shape of serviceAccountHandler but targeting arbitrary workload kinds
+// (Deployment, StatefulSet, DaemonSet, Job, CronJob) instead of ServiceAccounts.
+type Projector interface {
+	// Sync resolves the workloads that the secret should currently be projected into, as
+	// configured by the link's Selector.
+	Sync(ctx context.Context) ([]client.Object, error)
+
+	// LinkToSecret idempotently patches each of the given workloads so that the secret is
+	// projected into it, updating the ones that changed and recording the projection with the
+	// ObjectMarker.
+	LinkToSecret(ctx context.Context, workloads []client.Object, secret *corev1.Secret) error
+
+	// Unlink removes the secret's projection from a single workload's pod template. It returns
+	// true if the workload was modified and therefore needs to be persisted by the caller.
+	Unlink(secret *corev1.Secret, workload client.Object) bool
+}
+
+// ProjectorFactory creates a Projector configured for a single WorkloadLink.
+type ProjectorFactory func(target DeploymentTarget, marker ObjectMarker, link api.WorkloadLink) Projector
+
+// projectorRegistry maps the projection types supported by WorkloadLink.As to the factory that
+// knows how to build a Projector for them.
+var projectorRegistry = map[api.WorkloadProjectionType]ProjectorFactory{}
+
+// RegisterProjector registers a ProjectorFactory for the given projection type, overwriting any
+// previously registered one. It is meant to be called from package init() functions.
+func RegisterProjector(projectionType api.WorkloadProjectionType, factory ProjectorFactory) {
+	projectorRegistry[projectionType] = factory
+}
+
+func init() {
+	RegisterProjector(api.WorkloadProjectionTypeEnvFrom, newWorkloadProjector)
+	RegisterProjector(api.WorkloadProjectionTypeVolumeMount, newWorkloadProjector)
+	RegisterProjector(api.WorkloadProjectionTypeBindingDirectory, newWorkloadProjector)
+}
+
+// ProjectorFor looks up the Projector registered for link.As (defaulting to
+// WorkloadProjectionTypeBindingDirectory) and builds it for the given target and link.
+func ProjectorFor(target DeploymentTarget, marker ObjectMarker, link api.WorkloadLink) (Projector, error) {
+	projectionType := link.As
+	if projectionType == "" {
+		projectionType = api.WorkloadProjectionTypeBindingDirectory
+	}
+
+	factory, ok := projectorRegistry[projectionType]
+	if !ok {
+		return nil, fmt.Errorf("no projector registered for workload projection type %q", projectionType)
+	}
+
+	return factory(target, marker, link), nil
+}
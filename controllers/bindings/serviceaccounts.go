@@ -0,0 +1,545 @@
+//
+// Copyright (c) 2021 Red Hat, Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bindings
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	api "github.com/redhat-appstudio/remote-secret/api/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultTokenLifetime is used to size the rotation window when the link doesn't request a
+// specific TokenExpirationSeconds - it matches the default used by the TokenRequest API itself.
+const defaultTokenLifetime = time.Hour
+
+// defaultTokenRotationThreshold is the fraction of a projected token's lifetime that, once
+// remaining, triggers a rotation. Used when serviceAccountHandler.TokenRotationThreshold is unset.
+const defaultTokenRotationThreshold = 0.2
+
+// projectedTokenSecretKey and projectedTokenExpiryKey are the keys of the data stored in the
+// Secret holding a managed ServiceAccount's projected token.
+const (
+	projectedTokenSecretKey = "token"
+	projectedTokenExpiryKey = "expirationTimestamp"
+)
+
+// serviceAccountSecretDiffOpts is used to compare two ServiceAccount secrets while ignoring the
+// data fields that Kubernetes auto-generates for the legacy ServiceAccount token secrets and the
+// token and expiration fields stored for projected tokens, because their values are never equal
+// to what we'd put in the secret ourselves.
+var serviceAccountSecretDiffOpts = cmp.Options{
+	cmp.Transformer("bindings.ignoreAutoGeneratedServiceAccountSecretData", func(data map[string][]byte) map[string][]byte {
+		if len(data) == 0 {
+			return nil
+		}
+
+		filtered := map[string][]byte{}
+		for k, v := range data {
+			switch k {
+			case projectedTokenSecretKey, projectedTokenExpiryKey, "ca.crt", "namespace":
+				continue
+			default:
+				filtered[k] = v
+			}
+		}
+
+		if len(filtered) == 0 {
+			return nil
+		}
+
+		return filtered
+	}),
+}
+
+// serviceAccountHandler takes care of resolving, creating and marking the ServiceAccount(s) that
+// the secret of a DeploymentTarget should be linked to.
+type serviceAccountHandler struct {
+	Target       DeploymentTarget
+	ObjectMarker ObjectMarker
+
+	// TokenRotationThreshold is the fraction (0, 1] of a projected ServiceAccount token's lifetime
+	// that, once remaining, triggers a rotation of the token. Defaults to
+	// defaultTokenRotationThreshold when zero.
+	TokenRotationThreshold float64
+}
+
+// Sync makes sure that all the ServiceAccounts referenced or managed by the target's
+// LinkableSecretSpec exist and are correctly marked, and returns them so that the caller can link
+// the target secret to them (see LinkToSecret). For links that specify a ProvisionedService, the
+// name of the secret resolved from that service's `.status.binding.name` is returned in the
+// secretNames slice at the same index as the corresponding ServiceAccount; for all other links,
+// the corresponding entry is empty, meaning that the caller should use the target's own secret.
+func (h *serviceAccountHandler) Sync(ctx context.Context) ([]*corev1.ServiceAccount, []string, error) {
+	spec := h.Target.GetSpec()
+
+	sas := make([]*corev1.ServiceAccount, 0, len(spec.LinkedTo))
+	secretNames := make([]string, 0, len(spec.LinkedTo))
+
+	for i := range spec.LinkedTo {
+		link := spec.LinkedTo[i]
+
+		if !hasServiceAccountLink(link.ServiceAccount) {
+			continue
+		}
+
+		secretName := ""
+		if link.ProvisionedService != nil {
+			name, err := h.resolveProvisionedServiceSecretName(ctx, *link.ProvisionedService)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to resolve the provisioned service %s of kind %s: %w", link.ProvisionedService.Name, link.ProvisionedService.Kind, err)
+			}
+			secretName = name
+		}
+
+		sa, err := h.syncServiceAccount(ctx, link.ServiceAccount)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		sas = append(sas, sa)
+		secretNames = append(secretNames, secretName)
+	}
+
+	return sas, secretNames, nil
+}
+
+// hasServiceAccountLink returns true if the ServiceAccountLink actually references or describes a
+// ServiceAccount to link to.
+func hasServiceAccountLink(link api.ServiceAccountLink) bool {
+	return link.Reference.Name != "" || link.Managed.Name != "" || link.Managed.GenerateName != ""
+}
+
+// syncServiceAccount resolves (creating it if necessary) the single ServiceAccount described by
+// the link and makes sure it is correctly marked as managed or referenced by the current owner.
+func (h *serviceAccountHandler) syncServiceAccount(ctx context.Context, link api.ServiceAccountLink) (*corev1.ServiceAccount, error) {
+	cl := h.Target.GetClient()
+	ns := h.Target.GetTargetNamespace()
+
+	if link.Managed.Name != "" || link.Managed.GenerateName != "" {
+		return h.syncManagedServiceAccount(ctx, cl, ns, link.Managed)
+	}
+
+	return h.syncReferencedServiceAccount(ctx, cl, ns, link.Reference.Name)
+}
+
+func (h *serviceAccountHandler) syncManagedServiceAccount(ctx context.Context, cl client.Client, ns string, managed api.ManagedServiceAccountSpec) (*corev1.ServiceAccount, error) {
+	var key client.ObjectKey
+
+	if managed.Name != "" {
+		existing := &corev1.ServiceAccount{}
+		err := cl.Get(ctx, client.ObjectKey{Name: managed.Name, Namespace: ns}, existing)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to get the managed service account %s/%s: %w", ns, managed.Name, err)
+			}
+			created := newManagedServiceAccount(ns, managed)
+			if err := cl.Create(ctx, created); err != nil {
+				return nil, fmt.Errorf("failed to create the managed service account %s/%s: %w", ns, managed.Name, err)
+			}
+			key = client.ObjectKeyFromObject(created)
+		} else {
+			key = client.ObjectKeyFromObject(existing)
+		}
+	} else {
+		created := newManagedServiceAccount(ns, managed)
+		if err := cl.Create(ctx, created); err != nil {
+			return nil, fmt.Errorf("failed to create the managed service account with generateName %s in namespace %s: %w", managed.GenerateName, ns, err)
+		}
+		key = client.ObjectKeyFromObject(created)
+	}
+
+	sa, err := h.markManagedAndPersist(ctx, cl, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if usesProjectedToken(managed) {
+		if err := h.syncProjectedServiceAccountTokenSecret(ctx, cl, sa, managed); err != nil {
+			return nil, err
+		}
+	}
+
+	return sa, nil
+}
+
+// markManagedAndPersist marks the service account identified by key as managed by the current
+// owner and persists the change, retrying on update conflicts so that concurrent reconciles of
+// different owners sharing the same service account don't clobber each other's marking. The
+// ownership check is re-evaluated against the freshly-fetched object on every retry attempt, right
+// before the marking is applied, so that a racing owner can't slip in between the check and the
+// write and have its management silently overwritten.
+func (h *serviceAccountHandler) markManagedAndPersist(ctx context.Context, cl client.Client, key client.ObjectKey) (*corev1.ServiceAccount, error) {
+	sa := &corev1.ServiceAccount{}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := cl.Get(ctx, key, sa); err != nil {
+			return err
+		}
+
+		isManagedByOther, err := h.ObjectMarker.IsManagedByOther(ctx, sa)
+		if err != nil {
+			return err
+		}
+		if isManagedByOther {
+			return apierrors.NewForbidden(corev1.Resource("serviceaccounts"), key.Name, fmt.Errorf("the service account %s is already managed by another object", key))
+		}
+
+		if _, err := h.ObjectMarker.MarkManaged(ctx, key, sa); err != nil {
+			return err
+		}
+		return cl.Update(ctx, sa)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark the service account %s as managed: %w", key, err)
+	}
+
+	return sa, nil
+}
+
+// usesProjectedToken returns true if the managed ServiceAccount should use a projected, bound
+// token obtained through the TokenRequest API instead of the legacy auto-generated secret.
+func usesProjectedToken(managed api.ManagedServiceAccountSpec) bool {
+	return len(managed.TokenAudiences) > 0
+}
+
+// syncProjectedServiceAccountTokenSecret makes sure that the Secret holding the projected token of
+// the managed ServiceAccount exists and rotates its content once the token nears the end of its
+// lifetime.
+func (h *serviceAccountHandler) syncProjectedServiceAccountTokenSecret(ctx context.Context, cl client.Client, sa *corev1.ServiceAccount, managed api.ManagedServiceAccountSpec) error {
+	secret := &corev1.Secret{}
+	isNew := false
+
+	if err := cl.Get(ctx, client.ObjectKey{Name: sa.Name, Namespace: sa.Namespace}, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get the projected token secret %s/%s: %w", sa.Namespace, sa.Name, err)
+		}
+		isNew = true
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: sa.Name, Namespace: sa.Namespace},
+			Type:       corev1.SecretTypeOpaque,
+		}
+	}
+
+	if !isNew && !h.needsTokenRotation(secret, managed) {
+		return nil
+	}
+
+	tr := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         managed.TokenAudiences,
+			ExpirationSeconds: managed.TokenExpirationSeconds,
+		},
+	}
+
+	if err := cl.SubResource("token").Create(ctx, sa, tr); err != nil {
+		return fmt.Errorf("failed to request a projected token for the service account %s/%s: %w", sa.Namespace, sa.Name, err)
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[projectedTokenSecretKey] = []byte(tr.Status.Token)
+	secret.Data[projectedTokenExpiryKey] = []byte(tr.Status.ExpirationTimestamp.Format(time.RFC3339))
+
+	if isNew {
+		if err := cl.Create(ctx, secret); err != nil {
+			return fmt.Errorf("failed to create the projected token secret %s/%s: %w", sa.Namespace, sa.Name, err)
+		}
+	} else if err := cl.Update(ctx, secret); err != nil {
+		return fmt.Errorf("failed to update the projected token secret %s/%s: %w", sa.Namespace, sa.Name, err)
+	}
+
+	return nil
+}
+
+// needsTokenRotation determines whether the token stored in secret has gotten close enough to its
+// expiration (as configured by h.TokenRotationThreshold) that it should be re-issued.
+func (h *serviceAccountHandler) needsTokenRotation(secret *corev1.Secret, managed api.ManagedServiceAccountSpec) bool {
+	raw, ok := secret.Data[projectedTokenExpiryKey]
+	if !ok || len(raw) == 0 {
+		return true
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, string(raw))
+	if err != nil {
+		return true
+	}
+
+	lifetime := defaultTokenLifetime
+	if managed.TokenExpirationSeconds != nil {
+		lifetime = time.Duration(*managed.TokenExpirationSeconds) * time.Second
+	}
+
+	threshold := h.TokenRotationThreshold
+	if threshold <= 0 {
+		threshold = defaultTokenRotationThreshold
+	}
+
+	return time.Until(expiresAt) < time.Duration(float64(lifetime)*threshold)
+}
+
+func newManagedServiceAccount(ns string, managed api.ManagedServiceAccountSpec) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:         managed.Name,
+			GenerateName: managed.GenerateName,
+			Namespace:    ns,
+			Labels:       managed.Labels,
+			Annotations:  managed.Annotations,
+		},
+	}
+}
+
+func (h *serviceAccountHandler) syncReferencedServiceAccount(ctx context.Context, cl client.Client, ns string, name string) (*corev1.ServiceAccount, error) {
+	key := client.ObjectKey{Name: name, Namespace: ns}
+	sa := &corev1.ServiceAccount{}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := cl.Get(ctx, key, sa); err != nil {
+			return err
+		}
+
+		// the service account is no longer managed by us if it ever was - unmarking is idempotent
+		// and a no-op if the service account wasn't managed by us in the first place.
+		if _, err := h.ObjectMarker.UnmarkManaged(ctx, key, sa); err != nil {
+			return err
+		}
+
+		if _, err := h.ObjectMarker.MarkReferenced(ctx, key, sa); err != nil {
+			return err
+		}
+
+		return cl.Update(ctx, sa)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sync the referenced service account %s/%s: %w", ns, name, err)
+	}
+
+	return sa, nil
+}
+
+// resolveProvisionedServiceSecretName reads the `.status.binding.name` field of the object
+// referenced by ref to determine the name of the secret that should actually be linked, following
+// the "provisioned service" pattern of the Service Binding spec.
+func (h *serviceAccountHandler) resolveProvisionedServiceSecretName(ctx context.Context, ref api.ProvisionedServiceReference) (string, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(schema.GroupVersionKind{Group: ref.Group, Version: ref.Version, Kind: ref.Kind})
+
+	if err := h.Target.GetClient().Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: h.Target.GetTargetNamespace()}, obj); err != nil {
+		return "", fmt.Errorf("failed to get the provisioned service resource %s/%s: %w", h.Target.GetTargetNamespace(), ref.Name, err)
+	}
+
+	secretName, found, err := unstructured.NestedString(obj.Object, "status", "binding", "name")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the status.binding.name field of the provisioned service resource %s/%s: %w", h.Target.GetTargetNamespace(), ref.Name, err)
+	}
+	if !found || secretName == "" {
+		return "", fmt.Errorf("the provisioned service resource %s/%s does not yet expose status.binding.name", h.Target.GetTargetNamespace(), ref.Name)
+	}
+
+	return secretName, nil
+}
+
+// LinkToSecret links a secret to each of the provided ServiceAccounts, either as a regular secret
+// or as an image pull secret, depending on how the link is configured in the target's spec.
+// secretNames is the slice returned alongside serviceAccounts by Sync: for the ServiceAccount at
+// index i, secretNames[i] is linked if non-empty (the name resolved from a ProvisionedService),
+// otherwise the target's own secret is linked instead.
+func (h *serviceAccountHandler) LinkToSecret(ctx context.Context, serviceAccounts []*corev1.ServiceAccount, secretNames []string, secret *corev1.Secret) error {
+	spec := h.Target.GetSpec()
+
+	linkType := api.ServiceAccountLinkTypeSecret
+	for _, link := range spec.LinkedTo {
+		if hasServiceAccountLink(link.ServiceAccount) && link.ServiceAccount.As != "" {
+			linkType = link.ServiceAccount.As
+			break
+		}
+	}
+
+	cl := h.Target.GetClient()
+
+	for i, sa := range serviceAccounts {
+		secretName := secret.Name
+		if i < len(secretNames) && secretNames[i] != "" {
+			secretName = secretNames[i]
+		}
+
+		changed := false
+
+		switch linkType {
+		case api.ServiceAccountLinkTypeImagePullSecret:
+			if !containsLocalObjectRef(sa.ImagePullSecrets, secretName) {
+				sa.ImagePullSecrets = append(sa.ImagePullSecrets, corev1.LocalObjectReference{Name: secretName})
+				changed = true
+			}
+		default:
+			if !containsObjectRef(sa.Secrets, secretName) {
+				sa.Secrets = append(sa.Secrets, corev1.ObjectReference{Name: secretName})
+				changed = true
+			}
+		}
+
+		if changed {
+			if err := cl.Update(ctx, sa); err != nil {
+				return fmt.Errorf("failed to link the secret %s/%s to the service account %s/%s: %w", secret.Namespace, secretName, sa.Namespace, sa.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Finalize removes the current owner from the reference count of every ServiceAccount it
+// references or manages per its LinkableSecretSpec, deleting the managed ones once their
+// reference count drops to zero. It is meant to be called from the owning object's finalizer,
+// while its spec is still readable.
+func (h *serviceAccountHandler) Finalize(ctx context.Context) error {
+	spec := h.Target.GetSpec()
+	cl := h.Target.GetClient()
+	ns := h.Target.GetTargetNamespace()
+
+	for i := range spec.LinkedTo {
+		link := spec.LinkedTo[i].ServiceAccount
+		if !hasServiceAccountLink(link) {
+			continue
+		}
+
+		isManaged := link.Managed.Name != "" || link.Managed.GenerateName != ""
+
+		name := link.Reference.Name
+		if isManaged {
+			name = link.Managed.Name
+		}
+		if name == "" {
+			// a managed service account created through GenerateName has no stable name to look
+			// up once the owner itself is gone and no longer remembers the generated name -
+			// there's nothing left for us to clean up here.
+			continue
+		}
+
+		if err := h.finalizeServiceAccount(ctx, cl, client.ObjectKey{Name: name, Namespace: ns}, isManaged); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// finalizeServiceAccount removes the current owner's marking from the service account identified
+// by key, deleting it if isManaged is true and it has become orphaned as a result.
+func (h *serviceAccountHandler) finalizeServiceAccount(ctx context.Context, cl client.Client, key client.ObjectKey, isManaged bool) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		sa := &corev1.ServiceAccount{}
+		if err := cl.Get(ctx, key, sa); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+
+		if isManaged {
+			if _, err := h.ObjectMarker.UnmarkManaged(ctx, key, sa); err != nil {
+				return err
+			}
+		}
+		if _, err := h.ObjectMarker.UnmarkReferenced(ctx, key, sa); err != nil {
+			return err
+		}
+
+		if isManaged {
+			orphaned, err := h.ObjectMarker.IsOrphaned(ctx, sa)
+			if err != nil {
+				return err
+			}
+			if orphaned {
+				// the projected token secret (if any) is named after the service account and isn't
+				// owned by anything else - it must be cleaned up explicitly alongside the service
+				// account itself, or it (and the live, bound token it holds) would be leaked.
+				tokenSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+				if err := cl.Delete(ctx, tokenSecret); err != nil && !apierrors.IsNotFound(err) {
+					return err
+				}
+
+				if err := cl.Delete(ctx, sa); err != nil && !apierrors.IsNotFound(err) {
+					return err
+				}
+				return nil
+			}
+		}
+
+		return cl.Update(ctx, sa)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to finalize the service account %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// Unlink removes the secret from the Secrets and ImagePullSecrets of the ServiceAccount. It
+// returns true if the ServiceAccount was modified (the caller is responsible for persisting the
+// change).
+func (h *serviceAccountHandler) Unlink(secret *corev1.Secret, sa *corev1.ServiceAccount) bool {
+	changed := false
+
+	if idx := indexOfObjectRef(sa.Secrets, secret.Name); idx >= 0 {
+		sa.Secrets = append(sa.Secrets[:idx], sa.Secrets[idx+1:]...)
+		changed = true
+	}
+
+	if idx := indexOfLocalObjectRef(sa.ImagePullSecrets, secret.Name); idx >= 0 {
+		sa.ImagePullSecrets = append(sa.ImagePullSecrets[:idx], sa.ImagePullSecrets[idx+1:]...)
+		changed = true
+	}
+
+	return changed
+}
+
+func containsObjectRef(refs []corev1.ObjectReference, name string) bool {
+	return indexOfObjectRef(refs, name) >= 0
+}
+
+func indexOfObjectRef(refs []corev1.ObjectReference, name string) int {
+	for i, r := range refs {
+		if r.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func containsLocalObjectRef(refs []corev1.LocalObjectReference, name string) bool {
+	return indexOfLocalObjectRef(refs, name) >= 0
+}
+
+func indexOfLocalObjectRef(refs []corev1.LocalObjectReference, name string) int {
+	for i, r := range refs {
+		if r.Name == name {
+			return i
+		}
+	}
+	return -1
+}
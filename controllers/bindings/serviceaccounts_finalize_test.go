@@ -0,0 +1,190 @@
+//
+// Copyright (c) 2021 Red Hat, Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bindings
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	api "github.com/redhat-appstudio/remote-secret/api/v1beta1"
+	"github.com/redhat-appstudio/remote-secret/pkg/commaseparated"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func managedServiceAccountHandler(cl client.Client, ownerUID string) *serviceAccountHandler {
+	return &serviceAccountHandler{
+		Target: &TestDeploymentTarget{
+			GetClientImpl:          func() client.Client { return cl },
+			GetTargetNamespaceImpl: func() string { return "default" },
+			GetSpecImpl: func() api.LinkableSecretSpec {
+				return api.LinkableSecretSpec{
+					LinkedTo: []api.SecretLink{
+						{
+							ServiceAccount: api.ServiceAccountLink{
+								Managed: api.ManagedServiceAccountSpec{Name: "sa"},
+							},
+						},
+					},
+				}
+			},
+		},
+		ObjectMarker: &annotationObjectMarker{OwnerUID: types.UID(ownerUID)},
+	}
+}
+
+func TestServiceAccountHandlerConcurrentReferencers(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	// force exactly one update conflict so that the retry-on-conflict loop in
+	// markManagedAndPersist is actually exercised.
+	var once sync.Once
+	conflicted := false
+	cl := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "default"}}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Update: func(ctx context.Context, cl client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+				triggered := false
+				once.Do(func() {
+					triggered = true
+					conflicted = true
+				})
+				if triggered {
+					return apierrors.NewConflict(schema.GroupResource{Resource: "serviceaccounts"}, obj.GetName(), assert.AnError)
+				}
+				return cl.Update(ctx, obj, opts...)
+			},
+		}).
+		Build()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	owners := []string{"owner-a", "owner-b"}
+
+	for i := range owners {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			h := managedServiceAccountHandler(cl, owners[i])
+			_, _, err := h.Sync(context.TODO())
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+
+	assert.True(t, conflicted, "expected the injected conflict to have been triggered")
+
+	// exactly one of the two racing owners must win exclusive management of the service account;
+	// the other one must be rejected with a Forbidden error instead of silently overwriting the
+	// winner's management annotation.
+	successes := 0
+	forbidden := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			successes++
+		case apierrors.IsForbidden(err):
+			forbidden++
+		default:
+			assert.NoError(t, err, "unexpected error")
+		}
+	}
+	assert.Equal(t, 1, successes, "exactly one owner should have won management of the service account")
+	assert.Equal(t, 1, forbidden, "the losing owner should have been rejected with Forbidden")
+
+	sa := &corev1.ServiceAccount{}
+	assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: "sa", Namespace: "default"}, sa))
+
+	managedBy := sa.Annotations[managedByAnnotation]
+	assert.Contains(t, []string{"owner-a", "owner-b"}, managedBy)
+
+	linked := commaseparated.Value(sa.Annotations[linkedByAnnotation]).Values()
+	assert.Equal(t, []string{managedBy}, linked, "only the winning owner should be recorded as linked")
+}
+
+func TestServiceAccountHandlerFinalizeLastReferencerLeaves(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	sa := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sa",
+			Namespace: "default",
+			Annotations: map[string]string{
+				managedByAnnotation: "owner-a",
+				linkedByAnnotation:  "owner-a,owner-b",
+			},
+		},
+	}
+
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sa).Build()
+
+	a := managedServiceAccountHandler(cl, "owner-a")
+	b := managedServiceAccountHandler(cl, "owner-b")
+
+	assert.NoError(t, b.Finalize(context.TODO()))
+
+	stillThere := &corev1.ServiceAccount{}
+	assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: "sa", Namespace: "default"}, stillThere))
+	assert.Equal(t, []string{"owner-a"}, commaseparated.Value(stillThere.Annotations[linkedByAnnotation]).Values())
+
+	assert.NoError(t, a.Finalize(context.TODO()))
+
+	err := cl.Get(context.TODO(), client.ObjectKey{Name: "sa", Namespace: "default"}, &corev1.ServiceAccount{})
+	assert.True(t, apierrors.IsNotFound(err), "the managed service account should have been deleted once the last referencer left")
+}
+
+func TestServiceAccountHandlerFinalizeDeletesProjectedTokenSecret(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	sa := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sa",
+			Namespace: "default",
+			Annotations: map[string]string{
+				managedByAnnotation: "owner-a",
+				linkedByAnnotation:  "owner-a",
+			},
+		},
+	}
+	tokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "default"},
+		Data:       map[string][]byte{projectedTokenSecretKey: []byte("sometoken")},
+	}
+
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sa, tokenSecret).Build()
+
+	h := managedServiceAccountHandler(cl, "owner-a")
+
+	assert.NoError(t, h.Finalize(context.TODO()))
+
+	err := cl.Get(context.TODO(), client.ObjectKey{Name: "sa", Namespace: "default"}, &corev1.ServiceAccount{})
+	assert.True(t, apierrors.IsNotFound(err), "the managed service account should have been deleted once its last referencer left")
+
+	err = cl.Get(context.TODO(), client.ObjectKey{Name: "sa", Namespace: "default"}, &corev1.Secret{})
+	assert.True(t, apierrors.IsNotFound(err), "the projected token secret should have been deleted along with the service account")
+}
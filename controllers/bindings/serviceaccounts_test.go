@@ -18,17 +18,22 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	api "github.com/redhat-appstudio/remote-secret/api/v1beta1"
 	"github.com/redhat-appstudio/remote-secret/pkg/commaseparated"
 	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 )
 
 func TestServiceAccountSecretComparator(t *testing.T) {
@@ -563,7 +568,7 @@ func TestLinkSecretToServiceAccount(t *testing.T) {
 
 	t.Run("link as secret", func(t *testing.T) {
 		secretSpec.LinkedTo[0].ServiceAccount.As = ""
-		h.LinkToSecret(context.TODO(), []*corev1.ServiceAccount{sa}, secret)
+		h.LinkToSecret(context.TODO(), []*corev1.ServiceAccount{sa}, []string{""}, secret)
 
 		assert.Len(t, sa.Secrets, 1)
 		assert.Equal(t, sa.Secrets[0].Name, secret.Name)
@@ -577,7 +582,7 @@ func TestLinkSecretToServiceAccount(t *testing.T) {
 
 	t.Run("link as image pull secret", func(t *testing.T) {
 		secretSpec.LinkedTo[0].ServiceAccount.As = api.ServiceAccountLinkTypeImagePullSecret
-		h.LinkToSecret(context.TODO(), []*corev1.ServiceAccount{sa}, secret)
+		h.LinkToSecret(context.TODO(), []*corev1.ServiceAccount{sa}, []string{""}, secret)
 
 		assert.Len(t, sa.ImagePullSecrets, 1)
 		assert.Equal(t, sa.ImagePullSecrets[0].Name, secret.Name)
@@ -588,6 +593,25 @@ func TestLinkSecretToServiceAccount(t *testing.T) {
 		assert.Len(t, loadedSA.ImagePullSecrets, 1)
 		assert.Equal(t, loadedSA.ImagePullSecrets[0].Name, secret.Name)
 	})
+
+	t.Run("link resolved provisioned service secret instead of the target's own", func(t *testing.T) {
+		secretSpec.LinkedTo[0].ServiceAccount.As = ""
+		provisioned := &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "provisioned-sa", Namespace: "default"},
+		}
+		assert.NoError(t, cl.Create(context.TODO(), provisioned))
+
+		assert.NoError(t, h.LinkToSecret(context.TODO(), []*corev1.ServiceAccount{provisioned}, []string{"svc-secret"}, secret))
+
+		assert.Len(t, provisioned.Secrets, 1)
+		assert.Equal(t, "svc-secret", provisioned.Secrets[0].Name)
+
+		loadedSA := &corev1.ServiceAccount{}
+		assert.NoError(t, cl.Get(context.TODO(), client.ObjectKeyFromObject(provisioned), loadedSA))
+
+		assert.Len(t, loadedSA.Secrets, 1)
+		assert.Equal(t, "svc-secret", loadedSA.Secrets[0].Name)
+	})
 }
 
 func TestUnlinkSecretFromServiceAccount(t *testing.T) {
@@ -702,3 +726,226 @@ func TestUnlinkSecretFromServiceAccount(t *testing.T) {
 		assert.Len(t, sa.ImagePullSecrets, 0)
 	})
 }
+
+func provisionedServiceGVK() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Service"}
+}
+
+func TestResolveProvisionedServiceSecretName(t *testing.T) {
+	gvk := provisionedServiceGVK()
+
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+	scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(gvk.GroupVersion().WithKind(gvk.Kind+"List"), &unstructured.UnstructuredList{})
+
+	newProvisionedService := func(name string, boundSecretName string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		obj.SetName(name)
+		obj.SetNamespace("default")
+		if boundSecretName != "" {
+			assert.NoError(t, unstructured.SetNestedField(obj.Object, boundSecretName, "status", "binding", "name"))
+		}
+		return obj
+	}
+
+	h := serviceAccountHandler{
+		Target: &TestDeploymentTarget{
+			GetTargetNamespaceImpl: func() string { return "default" },
+		},
+		ObjectMarker: &TestObjectMarker{},
+	}
+
+	t.Run("resolves the bound secret name", func(t *testing.T) {
+		cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newProvisionedService("svc", "svc-secret")).Build()
+		h.Target.(*TestDeploymentTarget).GetClientImpl = func() client.Client { return cl }
+
+		name, err := h.resolveProvisionedServiceSecretName(context.TODO(), api.ProvisionedServiceReference{
+			Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind, Name: "svc",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "svc-secret", name)
+	})
+
+	t.Run("errors out if the binding is not ready yet", func(t *testing.T) {
+		cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newProvisionedService("svc", "")).Build()
+		h.Target.(*TestDeploymentTarget).GetClientImpl = func() client.Client { return cl }
+
+		_, err := h.resolveProvisionedServiceSecretName(context.TODO(), api.ProvisionedServiceReference{
+			Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind, Name: "svc",
+		})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("errors out if the referenced resource doesn't exist", func(t *testing.T) {
+		cl := fake.NewClientBuilder().WithScheme(scheme).Build()
+		h.Target.(*TestDeploymentTarget).GetClientImpl = func() client.Client { return cl }
+
+		_, err := h.resolveProvisionedServiceSecretName(context.TODO(), api.ProvisionedServiceReference{
+			Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind, Name: "svc",
+		})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestSyncResolvesProvisionedServiceSecretName(t *testing.T) {
+	gvk := provisionedServiceGVK()
+
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+	scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(gvk.GroupVersion().WithKind(gvk.Kind+"List"), &unstructured.UnstructuredList{})
+
+	svc := &unstructured.Unstructured{}
+	svc.SetGroupVersionKind(gvk)
+	svc.SetName("svc")
+	svc.SetNamespace("default")
+	assert.NoError(t, unstructured.SetNestedField(svc.Object, "svc-secret", "status", "binding", "name"))
+
+	sa := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "default"},
+	}
+
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(svc, sa).Build()
+
+	h := serviceAccountHandler{
+		Target: &TestDeploymentTarget{
+			GetClientImpl:          func() client.Client { return cl },
+			GetTargetNamespaceImpl: func() string { return "default" },
+			GetSpecImpl: func() api.LinkableSecretSpec {
+				return api.LinkableSecretSpec{
+					LinkedTo: []api.SecretLink{
+						{
+							ServiceAccount: api.ServiceAccountLink{
+								Reference: corev1.LocalObjectReference{Name: "sa"},
+							},
+							ProvisionedService: &api.ProvisionedServiceReference{
+								Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind, Name: "svc",
+							},
+						},
+					},
+				}
+			},
+		},
+		ObjectMarker: &TestObjectMarker{},
+	}
+
+	sas, secretNames, err := h.Sync(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, sas, 1)
+	assert.Equal(t, "sa", sas[0].Name)
+	assert.Equal(t, []string{"svc-secret"}, secretNames)
+
+	// the resolved provisioned service secret name must actually be what gets linked to the
+	// ServiceAccount, not the target's own secret.
+	targetSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "target-secret", Namespace: "default"}}
+	assert.NoError(t, h.LinkToSecret(context.TODO(), sas, secretNames, targetSecret))
+
+	loadedSA := &corev1.ServiceAccount{}
+	assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: "sa", Namespace: "default"}, loadedSA))
+	assert.Len(t, loadedSA.Secrets, 1)
+	assert.Equal(t, "svc-secret", loadedSA.Secrets[0].Name)
+}
+
+func TestManagedServiceAccountProjectedToken(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	requestedTokens := 0
+	tokenRequestInterceptor := interceptor.Funcs{
+		SubResourceCreate: func(ctx context.Context, cl client.Client, subResourceName string, obj client.Object, subResourceObj client.Object, opts ...client.SubResourceCreateOption) error {
+			requestedTokens++
+			tr, ok := subResourceObj.(*authenticationv1.TokenRequest)
+			if !ok {
+				t.Fatalf("expected a TokenRequest, got %T", subResourceObj)
+			}
+			assert.Equal(t, []string{"aud1"}, tr.Spec.Audiences, "the token request should have been made with the link's configured audiences")
+			tr.Status.Token = "the-token"
+			tr.Status.ExpirationTimestamp = metav1.NewTime(time.Now().Add(time.Duration(*tr.Spec.ExpirationSeconds) * time.Second))
+			return nil
+		},
+	}
+
+	deploymentTarget := &TestDeploymentTarget{
+		GetTargetNamespaceImpl: func() string { return "default" },
+		GetSpecImpl: func() api.LinkableSecretSpec {
+			return api.LinkableSecretSpec{
+				LinkedTo: []api.SecretLink{
+					{
+						ServiceAccount: api.ServiceAccountLink{
+							Managed: api.ManagedServiceAccountSpec{
+								Name:                   "sa",
+								TokenAudiences:         []string{"aud1"},
+								TokenExpirationSeconds: ptr.To(int64(3600)),
+							},
+						},
+					},
+				},
+			}
+		},
+	}
+
+	h := serviceAccountHandler{
+		Target:       deploymentTarget,
+		ObjectMarker: &TestObjectMarker{},
+	}
+
+	t.Run("requests a token and stores it", func(t *testing.T) {
+		cl := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(tokenRequestInterceptor).Build()
+		deploymentTarget.GetClientImpl = func() client.Client { return cl }
+
+		_, _, err := h.Sync(context.TODO())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, requestedTokens)
+
+		secret := &corev1.Secret{}
+		assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: "sa", Namespace: "default"}, secret))
+		assert.Equal(t, "the-token", string(secret.Data["token"]))
+		assert.NotEmpty(t, secret.Data["expirationTimestamp"])
+	})
+
+	t.Run("doesn't rotate a fresh token", func(t *testing.T) {
+		cl := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(tokenRequestInterceptor).Build()
+		deploymentTarget.GetClientImpl = func() client.Client { return cl }
+
+		requestedTokens = 0
+		_, _, err := h.Sync(context.TODO())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, requestedTokens)
+
+		_, _, err = h.Sync(context.TODO())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, requestedTokens, "a fresh token should not have been rotated")
+	})
+
+	t.Run("rotates a stale token", func(t *testing.T) {
+		cl := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(
+				&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "default"}},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "default"},
+					Data: map[string][]byte{
+						"token":               []byte("stale-token"),
+						"expirationTimestamp": []byte(time.Now().Add(time.Minute).Format(time.RFC3339)),
+					},
+				},
+			).
+			WithInterceptorFuncs(tokenRequestInterceptor).
+			Build()
+		deploymentTarget.GetClientImpl = func() client.Client { return cl }
+
+		requestedTokens = 0
+		_, _, err := h.Sync(context.TODO())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, requestedTokens, "a token nearing expiration should have been rotated")
+
+		secret := &corev1.Secret{}
+		assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: "sa", Namespace: "default"}, secret))
+		assert.Equal(t, "the-token", string(secret.Data["token"]))
+	})
+}
@@ -0,0 +1,116 @@
+//
+// Copyright (c) 2021 Red Hat, Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bindings
+
+import (
+	"context"
+
+	api "github.com/redhat-appstudio/remote-secret/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TestDeploymentTarget is a configurable DeploymentTarget implementation for use in the tests of
+// this package.
+type TestDeploymentTarget struct {
+	GetClientImpl          func() client.Client
+	GetSpecImpl            func() api.LinkableSecretSpec
+	GetTargetNamespaceImpl func() string
+}
+
+var _ DeploymentTarget = (*TestDeploymentTarget)(nil)
+
+func (t *TestDeploymentTarget) GetClient() client.Client {
+	if t.GetClientImpl == nil {
+		return nil
+	}
+	return t.GetClientImpl()
+}
+
+func (t *TestDeploymentTarget) GetSpec() api.LinkableSecretSpec {
+	if t.GetSpecImpl == nil {
+		return api.LinkableSecretSpec{}
+	}
+	return t.GetSpecImpl()
+}
+
+func (t *TestDeploymentTarget) GetTargetNamespace() string {
+	if t.GetTargetNamespaceImpl == nil {
+		return ""
+	}
+	return t.GetTargetNamespaceImpl()
+}
+
+// TestObjectMarker is a configurable ObjectMarker implementation for use in the tests of this
+// package.
+type TestObjectMarker struct {
+	MarkManagedImpl      func(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error)
+	UnmarkManagedImpl    func(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error)
+	MarkReferencedImpl   func(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error)
+	UnmarkReferencedImpl func(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error)
+	IsReferencedByImpl   func(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error)
+	IsManagedByOtherImpl func(ctx context.Context, obj client.Object) (bool, error)
+	IsOrphanedImpl       func(ctx context.Context, obj client.Object) (bool, error)
+}
+
+var _ ObjectMarker = (*TestObjectMarker)(nil)
+
+func (m *TestObjectMarker) MarkManaged(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error) {
+	if m.MarkManagedImpl == nil {
+		return false, nil
+	}
+	return m.MarkManagedImpl(ctx, key, obj)
+}
+
+func (m *TestObjectMarker) UnmarkManaged(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error) {
+	if m.UnmarkManagedImpl == nil {
+		return false, nil
+	}
+	return m.UnmarkManagedImpl(ctx, key, obj)
+}
+
+func (m *TestObjectMarker) MarkReferenced(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error) {
+	if m.MarkReferencedImpl == nil {
+		return false, nil
+	}
+	return m.MarkReferencedImpl(ctx, key, obj)
+}
+
+func (m *TestObjectMarker) UnmarkReferenced(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error) {
+	if m.UnmarkReferencedImpl == nil {
+		return false, nil
+	}
+	return m.UnmarkReferencedImpl(ctx, key, obj)
+}
+
+func (m *TestObjectMarker) IsReferencedBy(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error) {
+	if m.IsReferencedByImpl == nil {
+		return false, nil
+	}
+	return m.IsReferencedByImpl(ctx, key, obj)
+}
+
+func (m *TestObjectMarker) IsManagedByOther(ctx context.Context, obj client.Object) (bool, error) {
+	if m.IsManagedByOtherImpl == nil {
+		return false, nil
+	}
+	return m.IsManagedByOtherImpl(ctx, obj)
+}
+
+func (m *TestObjectMarker) IsOrphaned(ctx context.Context, obj client.Object) (bool, error) {
+	if m.IsOrphanedImpl == nil {
+		return false, nil
+	}
+	return m.IsOrphanedImpl(ctx, obj)
+}
@@ -0,0 +1,404 @@
+//
+// Copyright (c) 2021 Red Hat, Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bindings
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	api "github.com/redhat-appstudio/remote-secret/api/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// bindingDirectoryBasePath is the root under which secrets are mounted for the
+// WorkloadProjectionTypeBindingDirectory projection when MountPath is not specified, following
+// the Service Binding spec convention. WorkloadProjectionTypeVolumeMount never falls back to it.
+const bindingDirectoryBasePath = "/bindings"
+
+// workloadProjector is the built-in Projector implementation backing all 3 of the
+// WorkloadProjectionType strategies. The strategies only differ in how they patch a single pod
+// template (see project/unproject), so a single implementation handles the shared work of
+// resolving matching workloads and bookkeeping with the ObjectMarker.
+type workloadProjector struct {
+	Target       DeploymentTarget
+	ObjectMarker ObjectMarker
+	Link         api.WorkloadLink
+}
+
+var _ Projector = (*workloadProjector)(nil)
+
+func newWorkloadProjector(target DeploymentTarget, marker ObjectMarker, link api.WorkloadLink) Projector {
+	return &workloadProjector{Target: target, ObjectMarker: marker, Link: link}
+}
+
+func (p *workloadProjector) Sync(ctx context.Context) ([]client.Object, error) {
+	cl := p.Target.GetClient()
+	ns := p.Target.GetTargetNamespace()
+
+	list, err := newWorkloadList(p.Link.Kind)
+	if err != nil {
+		return nil, err
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&p.Link.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label selector in workload link: %w", err)
+	}
+
+	if err := cl.List(ctx, list, client.InNamespace(ns), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, fmt.Errorf("failed to list %s workloads in namespace %s: %w", p.Link.Kind, ns, err)
+	}
+
+	items, err := apimeta.ExtractList(list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract the %s workload list: %w", p.Link.Kind, err)
+	}
+
+	workloads := make([]client.Object, 0, len(items))
+	for _, item := range items {
+		obj, ok := item.(client.Object)
+		if !ok {
+			continue
+		}
+		workloads = append(workloads, obj)
+	}
+
+	return workloads, nil
+}
+
+func (p *workloadProjector) LinkToSecret(ctx context.Context, workloads []client.Object, secret *corev1.Secret) error {
+	cl := p.Target.GetClient()
+
+	matched := make(map[client.ObjectKey]bool, len(workloads))
+	for _, workload := range workloads {
+		matched[client.ObjectKeyFromObject(workload)] = true
+	}
+
+	if err := p.unlinkStale(ctx, matched, secret); err != nil {
+		return err
+	}
+
+	for _, workload := range workloads {
+		key := client.ObjectKeyFromObject(workload)
+
+		tmpl, err := podTemplateSpec(workload)
+		if err != nil {
+			return err
+		}
+
+		patched, err := p.project(tmpl, secret.Name)
+		if err != nil {
+			return fmt.Errorf("failed to project the secret %s/%s into the workload %s: %w", secret.Namespace, secret.Name, key, err)
+		}
+
+		marked, err := p.ObjectMarker.MarkReferenced(ctx, key, workload)
+		if err != nil {
+			return fmt.Errorf("failed to mark the workload %s as referenced: %w", key, err)
+		}
+
+		if !patched && !marked {
+			continue
+		}
+
+		if err := cl.Update(ctx, workload); err != nil {
+			return fmt.Errorf("failed to update the workload %s with the secret projection: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// unlinkStale finds the workloads of this link's Kind, in the target namespace, that this link
+// previously marked as referenced but that are no longer in matched - i.e. that fell out of the
+// link's Selector since the last sync (a label was removed, the selector was narrowed, etc.) - and
+// removes the secret's projection from them so it doesn't linger forever.
+func (p *workloadProjector) unlinkStale(ctx context.Context, matched map[client.ObjectKey]bool, secret *corev1.Secret) error {
+	cl := p.Target.GetClient()
+	ns := p.Target.GetTargetNamespace()
+
+	list, err := newWorkloadList(p.Link.Kind)
+	if err != nil {
+		return err
+	}
+
+	if err := cl.List(ctx, list, client.InNamespace(ns)); err != nil {
+		return fmt.Errorf("failed to list %s workloads in namespace %s: %w", p.Link.Kind, ns, err)
+	}
+
+	items, err := apimeta.ExtractList(list)
+	if err != nil {
+		return fmt.Errorf("failed to extract the %s workload list: %w", p.Link.Kind, err)
+	}
+
+	for _, item := range items {
+		workload, ok := item.(client.Object)
+		if !ok {
+			continue
+		}
+
+		key := client.ObjectKeyFromObject(workload)
+		if matched[key] {
+			continue
+		}
+
+		referenced, err := p.ObjectMarker.IsReferencedBy(ctx, key, workload)
+		if err != nil {
+			return fmt.Errorf("failed to determine whether the workload %s is referenced: %w", key, err)
+		}
+		if !referenced {
+			continue
+		}
+
+		patched := p.Unlink(secret, workload)
+
+		unmarked, err := p.ObjectMarker.UnmarkReferenced(ctx, key, workload)
+		if err != nil {
+			return fmt.Errorf("failed to unmark the workload %s as referenced: %w", key, err)
+		}
+
+		if !patched && !unmarked {
+			continue
+		}
+
+		if err := cl.Update(ctx, workload); err != nil {
+			return fmt.Errorf("failed to update the workload %s after removing the stale secret projection: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *workloadProjector) Unlink(secret *corev1.Secret, workload client.Object) bool {
+	tmpl, err := podTemplateSpec(workload)
+	if err != nil {
+		return false
+	}
+
+	return p.unproject(tmpl, secret.Name)
+}
+
+// project patches the pod template with the projection of secretName configured by the link. It
+// returns true if the pod template was actually modified.
+func (p *workloadProjector) project(tmpl *corev1.PodTemplateSpec, secretName string) (bool, error) {
+	if p.Link.As == api.WorkloadProjectionTypeEnvFrom {
+		return projectEnvFrom(tmpl, secretName), nil
+	}
+
+	mountPath, err := p.mountPath(secretName)
+	if err != nil {
+		return false, err
+	}
+
+	return projectVolumeMount(tmpl, secretName, mountPath), nil
+}
+
+// unproject removes a previously applied projection of secretName from the pod template. It
+// returns true if the pod template was actually modified.
+func (p *workloadProjector) unproject(tmpl *corev1.PodTemplateSpec, secretName string) bool {
+	if p.Link.As == api.WorkloadProjectionTypeEnvFrom {
+		return unprojectEnvFrom(tmpl, secretName)
+	}
+
+	return unprojectVolumeMount(tmpl, secretName)
+}
+
+// mountPath is the effective mount path to use for the VolumeMount and BindingDirectory
+// projection types. BindingDirectory defaults to a path derived from the secret name, following
+// the Service Binding spec convention, if MountPath is not set. VolumeMount has no such
+// convention to fall back on - a raw volume mount with no configured destination isn't
+// meaningful - so MountPath is required for it.
+func (p *workloadProjector) mountPath(secretName string) (string, error) {
+	if p.Link.MountPath != "" {
+		return p.Link.MountPath, nil
+	}
+
+	if p.Link.As == api.WorkloadProjectionTypeVolumeMount {
+		return "", fmt.Errorf("workload link of type %q requires mountPath to be set", api.WorkloadProjectionTypeVolumeMount)
+	}
+
+	return path.Join(bindingDirectoryBasePath, secretName), nil
+}
+
+// volumeNameFor derives a deterministic, idempotent volume name for a secret so that repeated
+// calls to project/unproject recognize the volume they themselves added.
+func volumeNameFor(secretName string) string {
+	return "binding-" + secretName
+}
+
+func projectEnvFrom(tmpl *corev1.PodTemplateSpec, secretName string) bool {
+	changed := false
+
+	for i := range tmpl.Spec.Containers {
+		container := &tmpl.Spec.Containers[i]
+		if containsEnvFromSecret(container.EnvFrom, secretName) {
+			continue
+		}
+
+		container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+			SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: secretName}},
+		})
+		changed = true
+	}
+
+	return changed
+}
+
+func unprojectEnvFrom(tmpl *corev1.PodTemplateSpec, secretName string) bool {
+	changed := false
+
+	for i := range tmpl.Spec.Containers {
+		container := &tmpl.Spec.Containers[i]
+
+		kept := container.EnvFrom[:0]
+		for _, ef := range container.EnvFrom {
+			if ef.SecretRef != nil && ef.SecretRef.Name == secretName {
+				changed = true
+				continue
+			}
+			kept = append(kept, ef)
+		}
+		container.EnvFrom = kept
+	}
+
+	return changed
+}
+
+func containsEnvFromSecret(envFrom []corev1.EnvFromSource, secretName string) bool {
+	for _, ef := range envFrom {
+		if ef.SecretRef != nil && ef.SecretRef.Name == secretName {
+			return true
+		}
+	}
+	return false
+}
+
+func projectVolumeMount(tmpl *corev1.PodTemplateSpec, secretName string, mountPath string) bool {
+	changed := false
+	volumeName := volumeNameFor(secretName)
+
+	hasVolume := false
+	for _, v := range tmpl.Spec.Volumes {
+		if v.Name == volumeName {
+			hasVolume = true
+			break
+		}
+	}
+	if !hasVolume {
+		tmpl.Spec.Volumes = append(tmpl.Spec.Volumes, corev1.Volume{
+			Name:         volumeName,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secretName}},
+		})
+		changed = true
+	}
+
+	for i := range tmpl.Spec.Containers {
+		container := &tmpl.Spec.Containers[i]
+
+		hasMount := false
+		for _, m := range container.VolumeMounts {
+			if m.Name == volumeName {
+				hasMount = true
+				break
+			}
+		}
+		if hasMount {
+			continue
+		}
+
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      volumeName,
+			MountPath: mountPath,
+			ReadOnly:  true,
+		})
+		changed = true
+	}
+
+	return changed
+}
+
+func unprojectVolumeMount(tmpl *corev1.PodTemplateSpec, secretName string) bool {
+	changed := false
+	volumeName := volumeNameFor(secretName)
+
+	keptVolumes := tmpl.Spec.Volumes[:0]
+	for _, v := range tmpl.Spec.Volumes {
+		if v.Name == volumeName {
+			changed = true
+			continue
+		}
+		keptVolumes = append(keptVolumes, v)
+	}
+	tmpl.Spec.Volumes = keptVolumes
+
+	for i := range tmpl.Spec.Containers {
+		container := &tmpl.Spec.Containers[i]
+
+		keptMounts := container.VolumeMounts[:0]
+		for _, m := range container.VolumeMounts {
+			if m.Name == volumeName {
+				changed = true
+				continue
+			}
+			keptMounts = append(keptMounts, m)
+		}
+		container.VolumeMounts = keptMounts
+	}
+
+	return changed
+}
+
+// podTemplateSpec returns a pointer to the pod template spec embedded in a supported workload
+// object.
+func podTemplateSpec(obj client.Object) (*corev1.PodTemplateSpec, error) {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return &o.Spec.Template, nil
+	case *appsv1.StatefulSet:
+		return &o.Spec.Template, nil
+	case *appsv1.DaemonSet:
+		return &o.Spec.Template, nil
+	case *batchv1.Job:
+		return &o.Spec.Template, nil
+	case *batchv1.CronJob:
+		return &o.Spec.JobTemplate.Spec.Template, nil
+	default:
+		return nil, fmt.Errorf("unsupported workload kind %T", obj)
+	}
+}
+
+// newWorkloadList returns an empty, typed list object for the given workload kind, suitable for
+// passing to client.Client.List.
+func newWorkloadList(kind string) (client.ObjectList, error) {
+	switch kind {
+	case "Deployment":
+		return &appsv1.DeploymentList{}, nil
+	case "StatefulSet":
+		return &appsv1.StatefulSetList{}, nil
+	case "DaemonSet":
+		return &appsv1.DaemonSetList{}, nil
+	case "Job":
+		return &batchv1.JobList{}, nil
+	case "CronJob":
+		return &batchv1.CronJobList{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported workload kind %q", kind)
+	}
+}
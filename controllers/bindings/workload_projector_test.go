@@ -0,0 +1,305 @@
+//
+// Copyright (c) 2021 Red Hat, Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bindings
+
+import (
+	"context"
+	"testing"
+
+	api "github.com/redhat-appstudio/remote-secret/api/v1beta1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func testDeployment(name string, labels map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "main"}},
+				},
+			},
+		},
+	}
+}
+
+func workloadScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, appsv1.AddToScheme(scheme))
+	assert.NoError(t, corev1.AddToScheme(scheme))
+	return scheme
+}
+
+func envFromProjector(cl client.Client, marker ObjectMarker) Projector {
+	target := &TestDeploymentTarget{
+		GetClientImpl:          func() client.Client { return cl },
+		GetTargetNamespaceImpl: func() string { return "default" },
+	}
+	link := api.WorkloadLink{
+		Kind:     "Deployment",
+		Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "my-app"}},
+		As:       api.WorkloadProjectionTypeEnvFrom,
+	}
+	p, err := ProjectorFor(target, marker, link)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func TestWorkloadProjectorEnvFrom(t *testing.T) {
+	scheme := workloadScheme(t)
+	dep := testDeployment("my-dep", map[string]string{"app": "my-app"})
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(dep).Build()
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "my-secret", Namespace: "default"}}
+	marker := &TestObjectMarker{
+		MarkReferencedImpl: func(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error) {
+			return true, nil
+		},
+	}
+
+	p := envFromProjector(cl, marker)
+
+	workloads, err := p.Sync(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, workloads, 1)
+
+	assert.NoError(t, p.LinkToSecret(context.TODO(), workloads, secret))
+
+	updated := &appsv1.Deployment{}
+	assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: "my-dep", Namespace: "default"}, updated))
+	assert.Len(t, updated.Spec.Template.Spec.Containers[0].EnvFrom, 1)
+	assert.Equal(t, "my-secret", updated.Spec.Template.Spec.Containers[0].EnvFrom[0].SecretRef.Name)
+
+	t.Run("linking again is idempotent", func(t *testing.T) {
+		assert.NoError(t, p.LinkToSecret(context.TODO(), []client.Object{updated}, secret))
+		assert.Len(t, updated.Spec.Template.Spec.Containers[0].EnvFrom, 1)
+	})
+
+	t.Run("unlink removes the projection", func(t *testing.T) {
+		changed := p.Unlink(secret, updated)
+		assert.True(t, changed)
+		assert.Empty(t, updated.Spec.Template.Spec.Containers[0].EnvFrom)
+	})
+}
+
+func TestWorkloadProjectorBindingDirectory(t *testing.T) {
+	scheme := workloadScheme(t)
+	dep := testDeployment("my-dep", map[string]string{"app": "my-app"})
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(dep).Build()
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "my-secret", Namespace: "default"}}
+
+	target := &TestDeploymentTarget{
+		GetClientImpl:          func() client.Client { return cl },
+		GetTargetNamespaceImpl: func() string { return "default" },
+	}
+	link := api.WorkloadLink{
+		Kind:     "Deployment",
+		Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "my-app"}},
+		As:       api.WorkloadProjectionTypeBindingDirectory,
+	}
+	p, err := ProjectorFor(target, &TestObjectMarker{}, link)
+	assert.NoError(t, err)
+
+	workloads, err := p.Sync(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, workloads, 1)
+
+	assert.NoError(t, p.LinkToSecret(context.TODO(), workloads, secret))
+
+	updated := &appsv1.Deployment{}
+	assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: "my-dep", Namespace: "default"}, updated))
+
+	assert.Len(t, updated.Spec.Template.Spec.Volumes, 1)
+	assert.Equal(t, "my-secret", updated.Spec.Template.Spec.Volumes[0].VolumeSource.Secret.SecretName)
+
+	mounts := updated.Spec.Template.Spec.Containers[0].VolumeMounts
+	assert.Len(t, mounts, 1)
+	assert.Equal(t, "/bindings/my-secret", mounts[0].MountPath, "BindingDirectory should default the mount path from the secret name")
+
+	t.Run("patching again is idempotent", func(t *testing.T) {
+		assert.NoError(t, p.LinkToSecret(context.TODO(), []client.Object{updated}, secret))
+		assert.Len(t, updated.Spec.Template.Spec.Volumes, 1)
+		assert.Len(t, updated.Spec.Template.Spec.Containers[0].VolumeMounts, 1)
+	})
+
+	t.Run("unlink removes the volume and the mount", func(t *testing.T) {
+		changed := p.Unlink(secret, updated)
+		assert.True(t, changed)
+		assert.Empty(t, updated.Spec.Template.Spec.Volumes)
+		assert.Empty(t, updated.Spec.Template.Spec.Containers[0].VolumeMounts)
+	})
+}
+
+func TestWorkloadProjectorVolumeMount(t *testing.T) {
+	scheme := workloadScheme(t)
+	dep := testDeployment("my-dep", map[string]string{"app": "my-app"})
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(dep).Build()
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "my-secret", Namespace: "default"}}
+
+	target := &TestDeploymentTarget{
+		GetClientImpl:          func() client.Client { return cl },
+		GetTargetNamespaceImpl: func() string { return "default" },
+	}
+	link := api.WorkloadLink{
+		Kind:      "Deployment",
+		Selector:  metav1.LabelSelector{MatchLabels: map[string]string{"app": "my-app"}},
+		As:        api.WorkloadProjectionTypeVolumeMount,
+		MountPath: "/etc/my-secret",
+	}
+	p, err := ProjectorFor(target, &TestObjectMarker{}, link)
+	assert.NoError(t, err)
+
+	workloads, err := p.Sync(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, workloads, 1)
+
+	assert.NoError(t, p.LinkToSecret(context.TODO(), workloads, secret))
+
+	updated := &appsv1.Deployment{}
+	assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: "my-dep", Namespace: "default"}, updated))
+
+	assert.Len(t, updated.Spec.Template.Spec.Volumes, 1)
+	assert.Equal(t, "my-secret", updated.Spec.Template.Spec.Volumes[0].VolumeSource.Secret.SecretName)
+
+	mounts := updated.Spec.Template.Spec.Containers[0].VolumeMounts
+	assert.Len(t, mounts, 1)
+	assert.Equal(t, "/etc/my-secret", mounts[0].MountPath, "VolumeMount should use the explicitly configured mount path, not the binding directory convention")
+}
+
+func TestWorkloadProjectorVolumeMountRequiresMountPath(t *testing.T) {
+	scheme := workloadScheme(t)
+	dep := testDeployment("my-dep", map[string]string{"app": "my-app"})
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(dep).Build()
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "my-secret", Namespace: "default"}}
+
+	target := &TestDeploymentTarget{
+		GetClientImpl:          func() client.Client { return cl },
+		GetTargetNamespaceImpl: func() string { return "default" },
+	}
+	link := api.WorkloadLink{
+		Kind:     "Deployment",
+		Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "my-app"}},
+		As:       api.WorkloadProjectionTypeVolumeMount,
+	}
+	p, err := ProjectorFor(target, &TestObjectMarker{}, link)
+	assert.NoError(t, err)
+
+	workloads, err := p.Sync(context.TODO())
+	assert.NoError(t, err)
+
+	assert.Error(t, p.LinkToSecret(context.TODO(), workloads, secret))
+}
+
+func TestWorkloadProjectorBookkeepsObjectMarker(t *testing.T) {
+	scheme := workloadScheme(t)
+	dep := testDeployment("my-dep", map[string]string{"app": "my-app"})
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(dep).Build()
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "my-secret", Namespace: "default"}}
+
+	var markedKeys []client.ObjectKey
+	marker := &TestObjectMarker{
+		MarkReferencedImpl: func(ctx context.Context, key client.ObjectKey, obj client.Object) (bool, error) {
+			markedKeys = append(markedKeys, key)
+			return true, nil
+		},
+	}
+
+	p := envFromProjector(cl, marker)
+
+	workloads, err := p.Sync(context.TODO())
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.LinkToSecret(context.TODO(), workloads, secret))
+	assert.Equal(t, []client.ObjectKey{{Name: "my-dep", Namespace: "default"}}, markedKeys)
+}
+
+func TestWorkloadProjectorUnmatchedWorkloadsAreNotPatched(t *testing.T) {
+	scheme := workloadScheme(t)
+	other := testDeployment("other-dep", map[string]string{"app": "other-app"})
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(other).Build()
+
+	p := envFromProjector(cl, &TestObjectMarker{})
+
+	workloads, err := p.Sync(context.TODO())
+	assert.NoError(t, err)
+	assert.Empty(t, workloads)
+}
+
+func TestWorkloadProjectorUnlinksOnSpecChange(t *testing.T) {
+	scheme := workloadScheme(t)
+	depA := testDeployment("dep-a", map[string]string{"app": "my-app"})
+	depB := testDeployment("dep-b", map[string]string{"app": "my-app"})
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(depA, depB).Build()
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "my-secret", Namespace: "default"}}
+	marker := &annotationObjectMarker{OwnerUID: "owner-x"}
+
+	target := &TestDeploymentTarget{
+		GetClientImpl:          func() client.Client { return cl },
+		GetTargetNamespaceImpl: func() string { return "default" },
+	}
+	link := api.WorkloadLink{
+		Kind:     "Deployment",
+		Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "my-app"}},
+		As:       api.WorkloadProjectionTypeEnvFrom,
+	}
+	p, err := ProjectorFor(target, marker, link)
+	assert.NoError(t, err)
+
+	workloads, err := p.Sync(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, workloads, 2)
+	assert.NoError(t, p.LinkToSecret(context.TODO(), workloads, secret))
+
+	loadedB := &appsv1.Deployment{}
+	assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: "dep-b", Namespace: "default"}, loadedB))
+	assert.Len(t, loadedB.Spec.Template.Spec.Containers[0].EnvFrom, 1, "precondition: dep-b should have been projected into")
+
+	// make dep-b fall out of the selector, as a spec change narrowing the selector would - here by
+	// changing dep-b's label so that it no longer matches.
+	loadedB.Labels = map[string]string{"app": "other-app"}
+	assert.NoError(t, cl.Update(context.TODO(), loadedB))
+
+	workloads, err = p.Sync(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, workloads, 1, "only dep-a should still match the selector")
+	assert.Equal(t, "dep-a", workloads[0].GetName())
+
+	assert.NoError(t, p.LinkToSecret(context.TODO(), workloads, secret))
+
+	unlinkedB := &appsv1.Deployment{}
+	assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: "dep-b", Namespace: "default"}, unlinkedB))
+	assert.Empty(t, unlinkedB.Spec.Template.Spec.Containers[0].EnvFrom, "dep-b should have had its stale projection removed once it fell out of the selector")
+
+	referenced, err := marker.IsReferencedBy(context.TODO(), client.ObjectKeyFromObject(unlinkedB), unlinkedB)
+	assert.NoError(t, err)
+	assert.False(t, referenced, "dep-b should no longer be marked as referenced by this link")
+
+	stillLinkedA := &appsv1.Deployment{}
+	assert.NoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: "dep-a", Namespace: "default"}, stillLinkedA))
+	assert.Len(t, stillLinkedA.Spec.Template.Spec.Containers[0].EnvFrom, 1, "dep-a's projection should be untouched")
+}
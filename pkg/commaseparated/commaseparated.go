@@ -0,0 +1,90 @@
+//
+// Copyright (c) 2021 Red Hat, Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package commaseparated provides a tiny helper for maintaining a set of
+// unique, comma-separated tokens stored in a single string, such as in an
+// annotation value. It keeps insertion order stable and never produces
+// duplicate entries.
+package commaseparated
+
+import "strings"
+
+// Value represents the comma-separated content of a single string value (e.g. an
+// annotation). The zero value represents an empty set.
+type Value string
+
+// Add returns a new Value with the given token appended, unless it is already present.
+func (v Value) Add(token string) Value {
+	if token == "" || v.Contains(token) {
+		return v
+	}
+
+	if v == "" {
+		return Value(token)
+	}
+
+	return Value(string(v) + "," + token)
+}
+
+// Remove returns a new Value with the given token removed, if present.
+func (v Value) Remove(token string) Value {
+	if token == "" || !v.Contains(token) {
+		return v
+	}
+
+	remaining := make([]string, 0, len(v.Values()))
+	for _, t := range v.Values() {
+		if t != token {
+			remaining = append(remaining, t)
+		}
+	}
+
+	return Value(strings.Join(remaining, ","))
+}
+
+// Contains returns true if the given token is present in the value.
+func (v Value) Contains(token string) bool {
+	for _, t := range v.Values() {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// Values splits the value into its individual tokens, skipping empty ones.
+func (v Value) Values() []string {
+	if v == "" {
+		return nil
+	}
+
+	parts := strings.Split(string(v), ",")
+	result := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// Len returns the number of tokens currently stored in the value.
+func (v Value) Len() int {
+	return len(v.Values())
+}
+
+// String returns the raw comma-separated representation.
+func (v Value) String() string {
+	return string(v)
+}